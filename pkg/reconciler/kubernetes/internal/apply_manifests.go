@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// installPhase orders the kinds of a manifest batch so that dependencies
+// (namespaces, CRDs, RBAC, ...) are installed before the objects that rely on them.
+type installPhase int
+
+const (
+	phaseNamespace installPhase = iota
+	phaseCRD
+	phaseRBAC
+	phaseConfig
+	phaseService
+	phasePVC
+	phasePV
+	phaseWorkload
+	phaseOther
+)
+
+var kindToPhase = map[string]installPhase{
+	"Namespace":                phaseNamespace,
+	"CustomResourceDefinition": phaseCRD,
+	"ServiceAccount":           phaseRBAC,
+	"Role":                     phaseRBAC,
+	"RoleBinding":              phaseRBAC,
+	"ClusterRole":              phaseRBAC,
+	"ClusterRoleBinding":       phaseRBAC,
+	"ConfigMap":                phaseConfig,
+	"Secret":                   phaseConfig,
+	"Service":                  phaseService,
+	"PersistentVolumeClaim":    phasePVC,
+	"PersistentVolume":         phasePV,
+	"Deployment":               phaseWorkload,
+	"StatefulSet":              phaseWorkload,
+	"DaemonSet":                phaseWorkload,
+	"Job":                      phaseWorkload,
+	"CronJob":                  phaseWorkload,
+}
+
+const (
+	defaultCRDEstablishedTimeout  = 60 * time.Second
+	defaultNamespaceActiveTimeout = 30 * time.Second
+	installPollInterval           = 500 * time.Millisecond
+)
+
+// ApplyOptions controls the behaviour of ApplyManifests and DeleteManifests.
+type ApplyOptions struct {
+	// ContinueOnError keeps applying/deleting the remaining manifests of a batch
+	// even if one manifest fails, instead of aborting on the first error.
+	ContinueOnError bool
+	// Rollback deletes every manifest that was successfully applied so far as soon
+	// as one manifest fails to apply. It has no effect when ContinueOnError or DryRun is true.
+	Rollback bool
+	// CRDEstablishedTimeout bounds how long ApplyManifests waits for a newly applied
+	// CustomResourceDefinition to become Established before moving to the next phase.
+	// Defaults to 60s when zero.
+	CRDEstablishedTimeout time.Duration
+	// NamespaceActiveTimeout bounds how long ApplyManifests waits for a newly created
+	// Namespace to reach the Active phase before applying resources into it.
+	// Defaults to 30s when zero.
+	NamespaceActiveTimeout time.Duration
+
+	// Mode selects the apply strategy used for every manifest in the batch: ClientSide
+	// (the default) or ServerSide. See ApplyWithOptions.
+	Mode ApplyMode
+	// FieldManager identifies this client's field ownership when Mode is ServerSide.
+	FieldManager string
+	// Force re-acquires ownership of fields owned by other managers when Mode is ServerSide.
+	Force bool
+	// DryRun submits the request without persisting any changes.
+	DryRun bool
+}
+
+// ApplyManifests installs a batch of manifests in a well-defined order: Namespaces,
+// CustomResourceDefinitions, RBAC, ConfigMaps/Secrets, Services, PVCs, PVs, workloads,
+// and finally everything else. It blocks between phases so that dependent resources
+// are only applied once their prerequisites are ready, removing the need for callers
+// to call Apply themselves in the right sequence.
+func (kube *KubeClient) ApplyManifests(manifests []*unstructured.Unstructured, opts ApplyOptions) ([]*Metadata, error) {
+	grouped := groupByPhase(manifests)
+
+	applied := make([]*Metadata, 0, len(manifests))
+	appliedManifests := make([]*unstructured.Unstructured, 0, len(manifests))
+	var failures []error
+
+	for phase := phaseNamespace; phase <= phaseOther; phase++ {
+		for _, u := range grouped[phase] {
+			result, err := kube.ApplyWithOptions(u, "", opts)
+			if err != nil {
+				wrapped := errors.Wrapf(err, "failed to apply %s '%s'", u.GetKind(), u.GetName())
+				if opts.Rollback && !opts.ContinueOnError && !opts.DryRun {
+					if _, delErr := kube.DeleteManifests(appliedManifests, ApplyOptions{ContinueOnError: true}); delErr != nil {
+						return applied, errors.Wrapf(wrapped, "rollback also failed: %s", delErr)
+					}
+					return applied, wrapped
+				}
+				if !opts.ContinueOnError {
+					return applied, wrapped
+				}
+				failures = append(failures, wrapped)
+				continue
+			}
+
+			applied = append(applied, result)
+			appliedManifests = append(appliedManifests, u)
+
+			switch phase {
+			case phaseNamespace:
+				if err := kube.waitForNamespaceActive(u.GetName(), orDefault(opts.NamespaceActiveTimeout, defaultNamespaceActiveTimeout)); err != nil {
+					if !opts.ContinueOnError {
+						return applied, err
+					}
+					failures = append(failures, err)
+				}
+			case phaseCRD:
+				if err := kube.waitForCRDEstablished(u.GetName(), orDefault(opts.CRDEstablishedTimeout, defaultCRDEstablishedTimeout)); err != nil {
+					if !opts.ContinueOnError {
+						return applied, err
+					}
+					failures = append(failures, err)
+					continue
+				}
+				kube.InvalidateDiscovery()
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return applied, &ErrManifestsFailed{Errors: failures}
+	}
+
+	return applied, nil
+}
+
+// ErrManifestsFailed is returned by ApplyManifests when opts.ContinueOnError let it keep going
+// past one or more manifest/readiness failures instead of aborting on the first one; Errors
+// preserves each failure in the order it occurred, so a caller that used ContinueOnError still
+// has a way to find out that (and which) manifests didn't make it.
+type ErrManifestsFailed struct {
+	Errors []error
+}
+
+func (e *ErrManifestsFailed) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d manifest(s) failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// DeleteManifests removes a batch of manifests in the reverse order that ApplyManifests
+// would install them in, so that e.g. workloads are torn down before the RBAC and
+// namespaces they depend on.
+func (kube *KubeClient) DeleteManifests(manifests []*unstructured.Unstructured, opts ApplyOptions) ([]*Metadata, error) {
+	grouped := groupByPhase(manifests)
+
+	deleted := make([]*Metadata, 0, len(manifests))
+	var failures []error
+	for phase := phaseOther; phase >= phaseNamespace; phase-- {
+		for _, u := range grouped[phase] {
+			result, err := kube.DeleteResourceByKindAndNameAndNamespace(u.GetKind(), u.GetName(), u.GetNamespace(), metav1.DeleteOptions{})
+			if err != nil {
+				wrapped := errors.Wrapf(err, "failed to delete %s '%s'", u.GetKind(), u.GetName())
+				if !opts.ContinueOnError {
+					return deleted, wrapped
+				}
+				failures = append(failures, wrapped)
+				continue
+			}
+			deleted = append(deleted, result)
+		}
+	}
+
+	if len(failures) > 0 {
+		return deleted, &ErrManifestsFailed{Errors: failures}
+	}
+
+	return deleted, nil
+}
+
+func groupByPhase(manifests []*unstructured.Unstructured) map[installPhase][]*unstructured.Unstructured {
+	grouped := make(map[installPhase][]*unstructured.Unstructured)
+	for _, u := range manifests {
+		phase, ok := kindToPhase[u.GetKind()]
+		if !ok {
+			phase = phaseOther
+		}
+		grouped[phase] = append(grouped[phase], u)
+	}
+	return grouped
+}
+
+func (kube *KubeClient) waitForNamespaceActive(name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ns, err := kube.Get("namespace", name, "")
+		if err != nil {
+			return errors.Wrapf(err, "failed to get Namespace '%s'", name)
+		}
+
+		if phase, found, _ := unstructured.NestedString(ns.Object, "status", "phase"); found && phase == "Active" {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return errors.Errorf("Namespace '%s' did not become Active within %s", name, timeout)
+		}
+		time.Sleep(installPollInterval)
+	}
+}
+
+func (kube *KubeClient) waitForCRDEstablished(name string, timeout time.Duration) error {
+	gvr := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		crd, err := kube.dynamicClient.Resource(gvr).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to get CustomResourceDefinition '%s'", name)
+		}
+
+		if crdConditionTrue(crd, "Established") && crdConditionTrue(crd, "NamesAccepted") {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return errors.Errorf("CustomResourceDefinition '%s' did not become Established within %s", name, timeout)
+		}
+		time.Sleep(installPollInterval)
+	}
+}
+
+func crdConditionTrue(crd *unstructured.Unstructured, condType string) bool {
+	conditions, found, err := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	if err != nil || !found {
+		return false
+	}
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if cond["type"] == condType && cond["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+func orDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/disk"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// DiscoveryCacheOptions configures the discovery cache backing a KubeClient's REST mapper.
+type DiscoveryCacheOptions struct {
+	// Disk swaps the default in-memory discovery cache, which never expires on its own, for an
+	// on-disk one with a TTL, so long-lived reconcilers pick up API changes across restarts.
+	Disk bool
+	// CacheDir is the directory the disk cache is written to. Ignored unless Disk is true;
+	// defaults to a "kubecache" directory under the OS temp dir.
+	CacheDir string
+	// TTL bounds how long cached discovery info is trusted before a fresh fetch is forced.
+	// Ignored unless Disk is true; defaults to 10 minutes.
+	TTL time.Duration
+}
+
+var defaultDiscoveryCacheOptions = DiscoveryCacheOptions{
+	CacheDir: filepath.Join(os.TempDir(), "kubecache"),
+	TTL:      10 * time.Minute,
+}
+
+// WithDiscoveryCache configures the discovery cache backing a KubeClient's REST mapper.
+func WithDiscoveryCache(opts DiscoveryCacheOptions) Option {
+	return func(kube *KubeClient) {
+		kube.discoveryCacheOptions = opts
+	}
+}
+
+// invalidateDiscoveryIfCRD calls InvalidateDiscovery whenever kind is CustomResourceDefinition,
+// so a newly-installed or updated CRD's GVKs resolve on the very next call instead of requiring
+// a caller to remember to invalidate the cache themselves.
+func (kube *KubeClient) invalidateDiscoveryIfCRD(kind string) {
+	if kind == "CustomResourceDefinition" {
+		kube.InvalidateDiscovery()
+	}
+}
+
+// InvalidateDiscovery clears the client's cached discovery info (REST mappings and the
+// discovery cache backing them) so that newly-registered GVKs - for instance from a CRD that
+// was just created - resolve on the next call instead of failing with a stale NoKindMatchError.
+func (kube *KubeClient) InvalidateDiscovery() {
+	kube.mapper.Reset()
+	kube.discoveryClient.Invalidate()
+}
+
+func getDiscoveryMapper(restConfig *rest.Config, opts DiscoveryCacheOptions) (*restmapper.DeferredDiscoveryRESTMapper, discovery.CachedDiscoveryInterface, error) {
+	var cached discovery.CachedDiscoveryInterface
+
+	if opts.Disk {
+		cacheDir := opts.CacheDir
+		if cacheDir == "" {
+			cacheDir = defaultDiscoveryCacheOptions.CacheDir
+		}
+		ttl := opts.TTL
+		if ttl <= 0 {
+			ttl = defaultDiscoveryCacheOptions.TTL
+		}
+
+		diskClient, err := disk.NewCachedDiscoveryClientForConfig(restConfig, cacheDir, "", ttl)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Failed to create disk-cached discovery client")
+		}
+		cached = diskClient
+	} else {
+		dc, err := discovery.NewDiscoveryClientForConfig(restConfig)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Failed to create new discovery client")
+		}
+		cached = memory.NewMemCacheClient(dc)
+	}
+
+	return restmapper.NewDeferredDiscoveryRESTMapper(cached), cached, nil
+}
+
+// kindFor resolves gvr to a GVK, invalidating the discovery cache and retrying once if the
+// mapper reports a NoKindMatchError - which happens when a CRD was installed after the cache
+// was last populated.
+func (kube *KubeClient) kindFor(gvr schema.GroupVersionResource) (schema.GroupVersionKind, error) {
+	gvk, err := kube.mapper.KindFor(gvr)
+	if meta.IsNoMatchError(err) {
+		kube.InvalidateDiscovery()
+		gvk, err = kube.mapper.KindFor(gvr)
+	}
+	return gvk, err
+}
+
+// resourceFor resolves gvr to the fully-qualified GVR, with the same NoKindMatchError retry
+// behaviour as kindFor.
+func (kube *KubeClient) resourceFor(gvr schema.GroupVersionResource) (schema.GroupVersionResource, error) {
+	resolved, err := kube.mapper.ResourceFor(gvr)
+	if meta.IsNoMatchError(err) {
+		kube.InvalidateDiscovery()
+		resolved, err = kube.mapper.ResourceFor(gvr)
+	}
+	return resolved, err
+}
+
+// restMappingFor resolves a RESTMapping for gk/version, with the same NoKindMatchError retry
+// behaviour as kindFor.
+func (kube *KubeClient) restMappingFor(gk schema.GroupKind, version string) (*meta.RESTMapping, error) {
+	restMapping, err := kube.mapper.RESTMapping(gk, version)
+	if meta.IsNoMatchError(err) {
+		kube.InvalidateDiscovery()
+		restMapping, err = kube.mapper.RESTMapping(gk, version)
+	}
+	return restMapping, err
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// jobDeleteTimeout bounds how long Update waits for a deleted Job to actually disappear
+// before recreating it.
+const jobDeleteTimeout = 30 * time.Second
+
+// JobHandler implements ResourceHandler for Job resources. Jobs are largely immutable once
+// created (most of spec.template is rejected on update), so reconciling an existing Job
+// deletes and recreates it rather than attempting an in-place replace.
+type JobHandler struct{}
+
+func (JobHandler) Get(conn KubernetesConnector, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return getViaConnector(conn, desired)
+}
+
+func (JobHandler) Create(conn KubernetesConnector, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return createViaConnector(conn, desired)
+}
+
+func (JobHandler) Update(conn KubernetesConnector, desired, existing *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if err := deleteViaConnector(conn, existing); err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	// Delete doesn't wait for the apiserver to finish tearing down the Job - which can take a
+	// while with ownerReferences/blockOwnerDeletion or a foreground propagation policy - so
+	// recreating immediately can race and come back as AlreadyExists/Conflict.
+	if err := waitForJobDeleted(conn, existing, jobDeleteTimeout); err != nil {
+		return nil, err
+	}
+
+	return createViaConnector(conn, desired)
+}
+
+// waitForJobDeleted polls until a Get for existing returns NotFound, or returns an error once
+// timeout elapses.
+func waitForJobDeleted(conn KubernetesConnector, existing *unstructured.Unstructured, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := getViaConnector(conn, existing)
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return errors.Errorf("Job '%s/%s' was not deleted within %s", existing.GetNamespace(), existing.GetName(), timeout)
+		}
+		time.Sleep(installPollInterval)
+	}
+}
+
+func (JobHandler) Delete(conn KubernetesConnector, existing *unstructured.Unstructured) error {
+	return deleteViaConnector(conn, existing)
+}
+
+func (JobHandler) Status(_ KubernetesConnector, existing *unstructured.Unstructured) (ResourceStatus, error) {
+	return jobStatus(existing), nil
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"github.com/pkg/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PVCHandler implements ResourceHandler for PersistentVolumeClaim resources, rejecting updates
+// that would shrink spec.resources.requests.storage, which the API server itself rejects, but
+// only after a failed apiserver round-trip.
+type PVCHandler struct{}
+
+func (PVCHandler) Get(conn KubernetesConnector, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return getViaConnector(conn, desired)
+}
+
+func (PVCHandler) Create(conn KubernetesConnector, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return createViaConnector(conn, desired)
+}
+
+func (PVCHandler) Update(conn KubernetesConnector, desired, existing *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	shrinking, err := pvcStorageRequestShrinking(desired, existing)
+	if err != nil {
+		return nil, err
+	}
+	if shrinking {
+		return nil, errors.Errorf(
+			"refusing to update PersistentVolumeClaim '%s/%s': spec.resources.requests.storage cannot shrink",
+			existing.GetNamespace(), existing.GetName(),
+		)
+	}
+
+	desired.SetResourceVersion(existing.GetResourceVersion())
+	return updateViaConnector(conn, desired)
+}
+
+func (PVCHandler) Delete(conn KubernetesConnector, existing *unstructured.Unstructured) error {
+	return deleteViaConnector(conn, existing)
+}
+
+func (PVCHandler) Status(_ KubernetesConnector, existing *unstructured.Unstructured) (ResourceStatus, error) {
+	return pvcStatus(existing), nil
+}
+
+func pvcStorageRequestShrinking(desired, existing *unstructured.Unstructured) (bool, error) {
+	desiredStorage, found, _ := unstructured.NestedString(desired.Object, "spec", "resources", "requests", "storage")
+	if !found {
+		return false, nil
+	}
+	existingStorage, found, _ := unstructured.NestedString(existing.Object, "spec", "resources", "requests", "storage")
+	if !found {
+		return false, nil
+	}
+
+	desiredQty, err := apiresource.ParseQuantity(desiredStorage)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid storage request quantity '%s'", desiredStorage)
+	}
+	existingQty, err := apiresource.ParseQuantity(existingStorage)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid storage request quantity '%s'", existingStorage)
+	}
+
+	return desiredQty.Cmp(existingQty) < 0, nil
+}
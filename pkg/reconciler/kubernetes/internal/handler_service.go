@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ServiceHandler implements ResourceHandler for Service resources, preserving the networking
+// fields the API server allocates (spec.clusterIP and each port's nodePort) across a replace.
+type ServiceHandler struct{}
+
+func (ServiceHandler) Get(conn KubernetesConnector, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return getViaConnector(conn, desired)
+}
+
+func (ServiceHandler) Create(conn KubernetesConnector, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return createViaConnector(conn, desired)
+}
+
+func (ServiceHandler) Update(conn KubernetesConnector, desired, existing *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	preserveServiceNetworking(desired, existing)
+	desired.SetResourceVersion(existing.GetResourceVersion())
+	return updateViaConnector(conn, desired)
+}
+
+func (ServiceHandler) Delete(conn KubernetesConnector, existing *unstructured.Unstructured) error {
+	return deleteViaConnector(conn, existing)
+}
+
+func (ServiceHandler) Status(_ KubernetesConnector, existing *unstructured.Unstructured) (ResourceStatus, error) {
+	return serviceStatus(existing), nil
+}
+
+// preserveServiceNetworking copies spec.clusterIP and each port's nodePort from the live
+// Service onto desired, since both are allocated by the API server and a replace that omits
+// or changes them is rejected (or silently reverted) by the apiserver.
+func preserveServiceNetworking(desired, existing *unstructured.Unstructured) {
+	if clusterIP, found, _ := unstructured.NestedString(existing.Object, "spec", "clusterIP"); found && clusterIP != "" {
+		_ = unstructured.SetNestedField(desired.Object, clusterIP, "spec", "clusterIP")
+	}
+
+	desiredPorts, found, _ := unstructured.NestedSlice(desired.Object, "spec", "ports")
+	if !found {
+		return
+	}
+	existingPorts, _, _ := unstructured.NestedSlice(existing.Object, "spec", "ports")
+
+	for _, dp := range desiredPorts {
+		desiredPort, ok := dp.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, ep := range existingPorts {
+			existingPort, ok := ep.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if desiredPort["name"] != existingPort["name"] && desiredPort["port"] != existingPort["port"] {
+				continue
+			}
+			if nodePort, ok := existingPort["nodePort"]; ok {
+				desiredPort["nodePort"] = nodePort
+			}
+			break
+		}
+	}
+
+	_ = unstructured.SetNestedSlice(desired.Object, desiredPorts, "spec", "ports")
+}
@@ -14,16 +14,17 @@ import (
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/util/flowcontrol"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 )
 
@@ -38,63 +39,101 @@ type Metadata struct {
 }
 
 type KubeClient struct {
-	dynamicClient dynamic.Interface
-	config        *rest.Config
-	mapper        *restmapper.DeferredDiscoveryRESTMapper
+	dynamicClient   dynamic.Interface
+	config          *rest.Config
+	mapper          *restmapper.DeferredDiscoveryRESTMapper
+	discoveryClient discovery.CachedDiscoveryInterface
+
+	allowedNamespaces  []string
+	deniedNamespaces   []string
+	allowClusterScoped bool
+
+	handlers map[schema.GroupVersionKind]ResourceHandler
+
+	retryOptions          RetryOptions
+	discoveryCacheOptions DiscoveryCacheOptions
 }
 
-func NewKubeClient(kubeconfig string, logger *zap.SugaredLogger) (*KubeClient, error) {
+// Option configures optional behaviour of a KubeClient at construction time.
+type Option func(*KubeClient)
+
+func NewKubeClient(kubeconfig string, logger *zap.SugaredLogger, opts ...Option) (*KubeClient, error) {
 	config, err := getRestConfig(kubeconfig)
 	if err != nil {
 		return nil, err
 	}
 
 	config.WarningHandler = &loggingWarningHandler{logger: logger}
-	return newForConfig(config)
+	return newForConfig(config, opts...)
 }
 
-func NewInClusterClient(logger *zap.SugaredLogger) (*KubeClient, error) {
+func NewInClusterClient(logger *zap.SugaredLogger, opts ...Option) (*KubeClient, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
 	}
 
 	config.WarningHandler = &loggingWarningHandler{logger: logger}
-	return newForConfig(config)
+	return newForConfig(config, opts...)
 }
 
-func newForConfig(config *rest.Config) (*KubeClient, error) {
-	dynamicClient, err := dynamic.NewForConfig(config)
+func newForConfig(config *rest.Config, opts ...Option) (*KubeClient, error) {
+	kube := &KubeClient{config: config}
+
+	for _, opt := range opts {
+		opt(kube)
+	}
+
+	// Options may have replaced kube.config's RateLimiter (see WithRateLimiter); fall back to a
+	// sensible default so bulk reconciliations never stampede the apiserver unconfigured.
+	if kube.config.RateLimiter == nil {
+		kube.config.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(defaultRateLimiterQPS, defaultRateLimiterBurst)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(kube.config)
 	if err != nil {
 		return nil, err
 	}
 
-	mapper, err := getDiscoveryMapper(config)
+	mapper, discoveryClient, err := getDiscoveryMapper(kube.config, kube.discoveryCacheOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	return &KubeClient{
-		dynamicClient: dynamicClient,
-		config:        config,
-		mapper:        mapper,
-	}, nil
+	kube.dynamicClient = dynamicClient
+	kube.mapper = mapper
+	kube.discoveryClient = discoveryClient
+
+	return kube, nil
 }
 
 func (kube *KubeClient) Apply(u *unstructured.Unstructured) (*Metadata, error) {
 	return kube.ApplyWithNamespaceOverride(u, "")
 }
 
-// ApplyWithNamespaceOverride applies a given manifest with an optional namespace to override.
+// ApplyWithNamespaceOverride applies a given manifest with an optional namespace to override,
+// using the client-side apply strategy. It is equivalent to calling ApplyWithOptions with a
+// zero-value ApplyOptions (Mode: ClientSide).
 // If no namespace is set on the manifest and no namespace override is passed in then we set the namespace to 'default'.
 // If namespaceOverride is empty it will NOT override the namespace set on the manifest.
 // We only override the namespace if the manifest is NOT cluster scoped (i.e. a ClusterRole) and namespaceOverride is NOT an
 // empty string.
 func (kube *KubeClient) ApplyWithNamespaceOverride(u *unstructured.Unstructured, namespaceOverride string) (*Metadata, error) {
+	return kube.ApplyWithOptions(u, namespaceOverride, ApplyOptions{})
+}
+
+// ApplyWithOptions applies a given manifest with an optional namespace to override, the same way
+// ApplyWithNamespaceOverride does, but additionally honors opts.Mode: ServerSide issues a
+// server-side apply Patch with field-manager and conflict handling instead of the client-side
+// get-then-create-or-replace path.
+// When a ResourceHandler is registered for the manifest's GVK and opts.DryRun is false, the
+// manifest is applied through that handler and opts.Mode has no effect. Dry runs always take the
+// generic path, since handlers have no dry-run mode.
+func (kube *KubeClient) ApplyWithOptions(u *unstructured.Unstructured, namespaceOverride string, opts ApplyOptions) (*Metadata, error) {
 	metadata := &Metadata{}
 	gvk := u.GroupVersionKind()
 
-	restMapping, err := kube.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	restMapping, err := kube.restMappingFor(gvk.GroupKind(), gvk.Version)
 	if err != nil {
 		return metadata, err
 	}
@@ -113,6 +152,25 @@ func (kube *KubeClient) ApplyWithNamespaceOverride(u *unstructured.Unstructured,
 
 	setNamespaceIfScoped(namespaceOverride, u, helper)
 
+	// A Namespace has no parent namespace of its own (helper.NamespaceScoped is false for it),
+	// but its name IS the namespace identity the filter exists to gate - check that instead of
+	// falling through to the AllowClusterScoped-only branch.
+	targetNamespace, clusterScoped := u.GetNamespace(), !helper.NamespaceScoped
+	if gvk.Kind == "Namespace" {
+		targetNamespace, clusterScoped = u.GetName(), false
+	}
+	if err := kube.checkNamespaceAllowed(targetNamespace, clusterScoped); err != nil {
+		return metadata, err
+	}
+
+	if handler, ok := kube.handlerFor(gvk); ok && !opts.DryRun {
+		return kube.applyWithHandler(handler, u)
+	}
+
+	if opts.Mode == ServerSide {
+		return kube.serverSideApply(restMapping, u, opts)
+	}
+
 	info := &resource.Info{
 		Client:          restClient,
 		Mapping:         restMapping,
@@ -128,7 +186,10 @@ func (kube *KubeClient) ApplyWithNamespaceOverride(u *unstructured.Unstructured,
 		}
 
 		// Then create the resource and skip the three-way merge
-		_, err := helper.Create(u.GetNamespace(), true, u)
+		err = kube.withRetry(func() error {
+			_, createErr := helper.DryRun(opts.DryRun).Create(u.GetNamespace(), true, u)
+			return createErr
+		})
 		if err != nil {
 			return metadata, err
 		}
@@ -136,11 +197,17 @@ func (kube *KubeClient) ApplyWithNamespaceOverride(u *unstructured.Unstructured,
 		metadata.Name = u.GetName()
 		metadata.Namespace = u.GetNamespace()
 		metadata.Kind = u.GroupVersionKind().Kind
+		kube.invalidateDiscoveryIfCRD(metadata.Kind)
 		return metadata, nil
 	}
 
-	replace := newReplace(helper)
-	replacedObject, err := replace(u, u.GetNamespace(), u.GetName())
+	replace := newReplace(helper.DryRun(opts.DryRun))
+	var replacedObject runtime.Object
+	err = kube.withRetry(func() error {
+		var replaceErr error
+		replacedObject, replaceErr = replace(u, u.GetNamespace(), u.GetName())
+		return replaceErr
+	})
 	if err != nil {
 		return metadata, err
 	}
@@ -150,6 +217,7 @@ func (kube *KubeClient) ApplyWithNamespaceOverride(u *unstructured.Unstructured,
 	metadata.Name = u.GetName()
 	metadata.Namespace = u.GetNamespace()
 	metadata.Kind = gvk.Kind
+	kube.invalidateDiscoveryIfCRD(metadata.Kind)
 
 	return metadata, nil
 }
@@ -159,7 +227,7 @@ func (kube *KubeClient) GetClientSet() (*kubernetes.Clientset, error) {
 }
 
 func (kube *KubeClient) DeleteResourceByKindAndNameAndNamespace(kind, name, namespace string, do metav1.DeleteOptions) (*Metadata, error) {
-	gvk, err := kube.mapper.KindFor(schema.GroupVersionResource{
+	gvk, err := kube.kindFor(schema.GroupVersionResource{
 		Resource: kind,
 	})
 	if err != nil {
@@ -172,7 +240,7 @@ func (kube *KubeClient) DeleteResourceByKindAndNameAndNamespace(kind, name, name
 		namespace = "default"
 	}
 
-	restMapping, err := kube.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	restMapping, err := kube.restMappingFor(gvk.GroupKind(), gvk.Version)
 	if err != nil {
 		return nil, err
 	}
@@ -184,16 +252,44 @@ func (kube *KubeClient) DeleteResourceByKindAndNameAndNamespace(kind, name, name
 
 	helper := resource.NewHelper(restClient, restMapping)
 
-	if helper.NamespaceScoped {
-		err = kube.dynamicClient.
-			Resource(restMapping.Resource).
-			Namespace(namespace).
-			Delete(context.TODO(), name, do)
-	} else {
-		err = kube.dynamicClient.
+	// Same special-case as ApplyWithOptions: a Namespace's identity is its own name, not its
+	// (always empty) namespace field, so check that against the filter instead of treating it
+	// as cluster-scoped.
+	targetNamespace, clusterScoped := namespace, !helper.NamespaceScoped
+	if isNamespaceResource {
+		targetNamespace, clusterScoped = name, false
+	}
+	if err := kube.checkNamespaceAllowed(targetNamespace, clusterScoped); err != nil {
+		return nil, err
+	}
+
+	if handler, ok := kube.handlerFor(gvk); ok {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(gvk)
+		existing.SetName(name)
+		existing.SetNamespace(namespace)
+
+		if err := kube.withRetry(func() error { return handler.Delete(kube, existing) }); err != nil {
+			return nil, err
+		}
+
+		if isNamespaceResource {
+			namespace = ""
+		}
+		return &Metadata{Kind: kind, Name: name, Namespace: namespace}, nil
+	}
+
+	err = kube.withRetry(func() error {
+		if helper.NamespaceScoped {
+			return kube.dynamicClient.
+				Resource(restMapping.Resource).
+				Namespace(namespace).
+				Delete(context.TODO(), name, do)
+		}
+		return kube.dynamicClient.
 			Resource(restMapping.Resource).
 			Delete(context.TODO(), name, do)
-	}
+	})
 
 	//return deleted resource
 	if isNamespaceResource {
@@ -209,12 +305,12 @@ func (kube *KubeClient) DeleteResourceByKindAndNameAndNamespace(kind, name, name
 // Get a manifest by resource/kind (example: 'pods' or 'pod'),
 // name (example: 'my-pod'), and namespace (example: 'my-namespace').
 func (kube *KubeClient) Get(kind, name, namespace string) (*unstructured.Unstructured, error) {
-	gvk, err := kube.mapper.KindFor(schema.GroupVersionResource{Resource: kind})
+	gvk, err := kube.kindFor(schema.GroupVersionResource{Resource: kind})
 	if err != nil {
 		return nil, err
 	}
 
-	restMapping, err := kube.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	restMapping, err := kube.restMappingFor(gvk.GroupKind(), gvk.Version)
 	if err != nil {
 		return nil, err
 	}
@@ -227,27 +323,53 @@ func (kube *KubeClient) Get(kind, name, namespace string) (*unstructured.Unstruc
 	var u *unstructured.Unstructured
 
 	helper := resource.NewHelper(restClient, restMapping)
-	if helper.NamespaceScoped {
-		u, err = kube.dynamicClient.
-			Resource(restMapping.Resource).
-			Namespace(namespace).
-			Get(context.TODO(), name, metav1.GetOptions{})
-	} else {
-		u, err = kube.dynamicClient.
-			Resource(restMapping.Resource).
-			Get(context.TODO(), name, metav1.GetOptions{})
-	}
+	err = kube.withRetry(func() error {
+		var getErr error
+		if helper.NamespaceScoped {
+			u, getErr = kube.dynamicClient.
+				Resource(restMapping.Resource).
+				Namespace(namespace).
+				Get(context.TODO(), name, metav1.GetOptions{})
+		} else {
+			u, getErr = kube.dynamicClient.
+				Resource(restMapping.Resource).
+				Get(context.TODO(), name, metav1.GetOptions{})
+		}
+		return getErr
+	})
 
 	return u, err
 }
 
 // ListResource lists all resources by their kind or resource (e.g. "replicaset" or "replicasets").
+// When a namespace filter is configured, results are restricted to allowed namespaces: via a
+// server-side FieldSelector if the allow-list names a single namespace, or a client-side filter
+// otherwise (e.g. a deny-list, or an allow-list with more than one namespace).
 func (kube *KubeClient) ListResource(resource string, lo metav1.ListOptions) (*unstructured.UnstructuredList, error) {
-	gvr, err := kube.mapper.ResourceFor(schema.GroupVersionResource{Resource: resource})
+	gvr, err := kube.resourceFor(schema.GroupVersionResource{Resource: resource})
 	if err != nil {
 		return nil, err
 	}
-	return kube.dynamicClient.Resource(gvr).List(context.TODO(), lo)
+
+	if len(kube.allowedNamespaces) == 1 {
+		lo.FieldSelector = appendFieldSelector(lo.FieldSelector, "metadata.namespace", kube.allowedNamespaces[0])
+	}
+
+	var list *unstructured.UnstructuredList
+	err = kube.withRetry(func() error {
+		var listErr error
+		list, listErr = kube.dynamicClient.Resource(gvr).List(context.TODO(), lo)
+		return listErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(kube.allowedNamespaces) != 1 && (len(kube.allowedNamespaces) > 0 || len(kube.deniedNamespaces) > 0) {
+		list.Items = filterByNamespace(list.Items, kube.allowedNamespaces, kube.deniedNamespaces)
+	}
+
+	return list, nil
 }
 
 func (kube *KubeClient) Patch(kind, name, namespace string, p []byte) (*Metadata, *unstructured.Unstructured, error) {
@@ -256,12 +378,12 @@ func (kube *KubeClient) Patch(kind, name, namespace string, p []byte) (*Metadata
 
 func (kube *KubeClient) PatchUsingStrategy(kind, name, namespace string, p []byte, strategy types.PatchType) (*Metadata, *unstructured.Unstructured, error) {
 	metadata := &Metadata{}
-	gvk, err := kube.mapper.KindFor(schema.GroupVersionResource{Resource: kind})
+	gvk, err := kube.kindFor(schema.GroupVersionResource{Resource: kind})
 	if err != nil {
 		return metadata, nil, err
 	}
 
-	restMapping, err := kube.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	restMapping, err := kube.restMappingFor(gvk.GroupKind(), gvk.Version)
 	if err != nil {
 		return metadata, nil, err
 	}
@@ -273,19 +395,32 @@ func (kube *KubeClient) PatchUsingStrategy(kind, name, namespace string, p []byt
 
 	helper := resource.NewHelper(restClient, restMapping)
 
-	var u *unstructured.Unstructured
-
-	if helper.NamespaceScoped {
-		u, err = kube.dynamicClient.
-			Resource(restMapping.Resource).
-			Namespace(namespace).
-			Patch(context.TODO(), name, strategy, p, metav1.PatchOptions{})
-	} else {
-		u, err = kube.dynamicClient.
-			Resource(restMapping.Resource).
-			Patch(context.TODO(), name, strategy, p, metav1.PatchOptions{})
+	// Same special-case as ApplyWithOptions/DeleteResourceByKindAndNameAndNamespace: a
+	// Namespace's identity is its own name, not its (always empty) namespace field.
+	targetNamespace, clusterScoped := namespace, !helper.NamespaceScoped
+	if strings.ToLower(gvk.Kind) == "namespace" {
+		targetNamespace, clusterScoped = name, false
+	}
+	if err := kube.checkNamespaceAllowed(targetNamespace, clusterScoped); err != nil {
+		return metadata, nil, err
 	}
 
+	var u *unstructured.Unstructured
+
+	err = kube.withRetry(func() error {
+		var patchErr error
+		if helper.NamespaceScoped {
+			u, patchErr = kube.dynamicClient.
+				Resource(restMapping.Resource).
+				Namespace(namespace).
+				Patch(context.TODO(), name, strategy, p, metav1.PatchOptions{})
+		} else {
+			u, patchErr = kube.dynamicClient.
+				Resource(restMapping.Resource).
+				Patch(context.TODO(), name, strategy, p, metav1.PatchOptions{})
+		}
+		return patchErr
+	})
 	if err != nil {
 		return metadata, nil, err
 	}
@@ -303,6 +438,10 @@ func (kube *KubeClient) PatchUsingStrategy(kind, name, namespace string, p []byt
 }
 
 func (kube *KubeClient) DeleteNamespace(namespace string) error {
+	if err := kube.checkNamespaceAllowed(namespace, false); err != nil {
+		return err
+	}
+
 	getter := NewRESTClientGetter(kube.config)
 	factory := cmdutil.NewFactory(getter)
 	r := factory.NewBuilder().
@@ -322,9 +461,11 @@ func (kube *KubeClient) DeleteNamespace(namespace string) error {
 	}
 	if len(infos) == 0 {
 		namespaceRes := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
-		err = kube.dynamicClient.
-			Resource(namespaceRes).
-			Delete(context.TODO(), namespace, metav1.DeleteOptions{})
+		err = kube.withRetry(func() error {
+			return kube.dynamicClient.
+				Resource(namespaceRes).
+				Delete(context.TODO(), namespace, metav1.DeleteOptions{})
+		})
 	}
 	return err
 }
@@ -342,17 +483,6 @@ func newRestClient(restConfig rest.Config, gv schema.GroupVersion) (rest.Interfa
 	return rest.RESTClientFor(&restConfig)
 }
 
-func getDiscoveryMapper(restConfig *rest.Config) (*restmapper.DeferredDiscoveryRESTMapper, error) {
-	// Prepare a RESTMapper to find GVR
-	dc, err := discovery.NewDiscoveryClientForConfig(restConfig)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to create new discovery client")
-	}
-
-	discoveryMapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
-	return discoveryMapper, nil
-}
-
 func getRestConfig(kubeconfig string) (*rest.Config, error) {
 	return clientcmd.BuildConfigFromKubeconfigGetter("", func() (config *clientcmdapi.Config, e error) {
 		return clientcmd.Load([]byte(kubeconfig))
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ErrNamespaceNotAllowed is returned by mutating KubeClient operations when the effective
+// target namespace is outside the configured allow-list or inside the configured deny-list.
+type ErrNamespaceNotAllowed struct {
+	Namespace string
+}
+
+func (e *ErrNamespaceNotAllowed) Error() string {
+	return fmt.Sprintf("namespace '%s' is not allowed by the configured namespace filter", e.Namespace)
+}
+
+// WithNamespaceFilter restricts a KubeClient to a subset of a cluster: only namespaces named
+// in allowed (when non-empty) and not named in denied may be targeted by mutating operations.
+// allowClusterScoped controls whether cluster-scoped resources (which have no namespace to
+// check) may still be targeted; it only takes effect when allowed or denied is non-empty, so with
+// both lists empty cluster-scoped resources are always allowed.
+func WithNamespaceFilter(allowed, denied []string, allowClusterScoped bool) Option {
+	return func(kube *KubeClient) {
+		kube.allowedNamespaces = allowed
+		kube.deniedNamespaces = denied
+		kube.allowClusterScoped = allowClusterScoped
+	}
+}
+
+// checkNamespaceAllowed verifies that namespace may be targeted under the client's namespace
+// filter. clusterScoped resources bypass the allow/deny lists and are instead gated by the
+// AllowClusterScoped flag.
+func (kube *KubeClient) checkNamespaceAllowed(namespace string, clusterScoped bool) error {
+	if clusterScoped {
+		if !kube.allowClusterScoped && (len(kube.allowedNamespaces) > 0 || len(kube.deniedNamespaces) > 0) {
+			return &ErrNamespaceNotAllowed{Namespace: namespace}
+		}
+		return nil
+	}
+
+	for _, denied := range kube.deniedNamespaces {
+		if denied == namespace {
+			return &ErrNamespaceNotAllowed{Namespace: namespace}
+		}
+	}
+
+	if len(kube.allowedNamespaces) == 0 {
+		return nil
+	}
+
+	for _, allowed := range kube.allowedNamespaces {
+		if allowed == namespace {
+			return nil
+		}
+	}
+
+	return &ErrNamespaceNotAllowed{Namespace: namespace}
+}
+
+// filterByNamespace client-side filters a list of unstructured items down to those whose
+// namespace is allowed (when allowed is non-empty) and not denied.
+func filterByNamespace(items []unstructured.Unstructured, allowed, denied []string) []unstructured.Unstructured {
+	filtered := items[:0]
+	for _, item := range items {
+		ns := item.GetNamespace()
+
+		isDenied := false
+		for _, d := range denied {
+			if d == ns {
+				isDenied = true
+				break
+			}
+		}
+		if isDenied {
+			continue
+		}
+
+		if len(allowed) > 0 {
+			isAllowed := false
+			for _, a := range allowed {
+				if a == ns {
+					isAllowed = true
+					break
+				}
+			}
+			if !isAllowed {
+				continue
+			}
+		}
+
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// appendFieldSelector adds a field=value requirement to an existing field selector string.
+func appendFieldSelector(existing, field, value string) string {
+	requirement := fmt.Sprintf("%s=%s", field, value)
+	if existing == "" {
+		return requirement
+	}
+	return existing + "," + requirement
+}
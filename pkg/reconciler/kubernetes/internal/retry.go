@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// RetryOptions configures the exponential backoff KubeClient uses to retry transient apiserver
+// errors across all of its calls (Apply, Get, ListResource, Patch*, Delete*).
+type RetryOptions struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsed      time.Duration
+	MaxRetries      uint64
+}
+
+var defaultRetryOptions = RetryOptions{
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     10 * time.Second,
+	MaxElapsed:      time.Minute,
+	MaxRetries:      5,
+}
+
+const (
+	defaultRateLimiterQPS   = 20
+	defaultRateLimiterBurst = 40
+)
+
+// WithRetryOptions overrides the default retry behaviour of a KubeClient.
+func WithRetryOptions(opts RetryOptions) Option {
+	return func(kube *KubeClient) {
+		kube.retryOptions = opts
+	}
+}
+
+// WithRateLimiter attaches a client-side token-bucket rate limiter (QPS/Burst) to the
+// KubeClient's rest.Config, so that bulk reconciliations don't stampede the apiserver.
+func WithRateLimiter(qps float32, burst int) Option {
+	return func(kube *KubeClient) {
+		kube.config.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	}
+}
+
+func (kube *KubeClient) newBackOff() backoff.BackOff {
+	opts := kube.retryOptions
+	if opts.InitialInterval <= 0 {
+		opts = defaultRetryOptions
+	}
+
+	exp := backoff.NewExponentialBackOff()
+	exp.InitialInterval = opts.InitialInterval
+	exp.MaxInterval = opts.MaxInterval
+	exp.MaxElapsedTime = opts.MaxElapsed
+
+	var b backoff.BackOff = exp
+	if opts.MaxRetries > 0 {
+		b = backoff.WithMaxRetries(exp, opts.MaxRetries)
+	}
+	return b
+}
+
+// withRetry retries op using the client's configured exponential backoff, retrying only on
+// transient apiserver errors (server timeout, 429, internal error, service unavailable, or a
+// network error) and returning immediately on anything else, such as NotFound, Forbidden,
+// Invalid, or Conflict.
+func (kube *KubeClient) withRetry(op func() error) error {
+	return backoff.Retry(func() error {
+		err := op()
+		if err == nil {
+			return nil
+		}
+		if !isRetryableError(err) {
+			return backoff.Permanent(err)
+		}
+		if wait, ok := retryAfter(err); ok {
+			time.Sleep(wait)
+		}
+		return err
+	}, kube.newBackOff())
+}
+
+func isRetryableError(err error) bool {
+	switch {
+	case k8serrors.IsNotFound(err), k8serrors.IsForbidden(err), k8serrors.IsInvalid(err), k8serrors.IsConflict(err):
+		return false
+	case k8serrors.IsServerTimeout(err), k8serrors.IsTooManyRequests(err), k8serrors.IsInternalError(err), k8serrors.IsServiceUnavailable(err):
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryAfter extracts the Retry-After duration the apiserver attached to a 429 response, if any.
+func retryAfter(err error) (time.Duration, bool) {
+	var statusErr *k8serrors.StatusError
+	if !errors.As(err, &statusErr) {
+		return 0, false
+	}
+	if statusErr.ErrStatus.Details == nil || statusErr.ErrStatus.Details.RetryAfterSeconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(statusErr.ErrStatus.Details.RetryAfterSeconds) * time.Second, true
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// ApplyMode selects the strategy ApplyWithOptions uses to reconcile a manifest against the
+// live object in the cluster.
+type ApplyMode int
+
+const (
+	// ClientSide performs a get-then-create-or-replace, the behaviour ApplyWithNamespaceOverride
+	// has always had.
+	ClientSide ApplyMode = iota
+	// ServerSide issues a Server-Side Apply patch, letting the API server merge field ownership
+	// across actors instead of replacing the whole object.
+	ServerSide
+)
+
+const defaultFieldManager = "reconciler"
+
+// ErrFieldOwnershipConflict is returned when a Server-Side Apply patch is rejected because
+// another field manager owns one or more of the fields being applied, and Force was not set.
+type ErrFieldOwnershipConflict struct {
+	Namespace           string
+	Name                string
+	ConflictingManagers []string
+}
+
+func (e *ErrFieldOwnershipConflict) Error() string {
+	return fmt.Sprintf(
+		"failed to apply '%s/%s': conflicting field managers %s; retry with Force=true to take ownership",
+		e.Namespace, e.Name, strings.Join(e.ConflictingManagers, ", "),
+	)
+}
+
+// serverSideApply applies u via a Server-Side Apply patch (types.ApplyPatchType), honoring
+// opts.FieldManager, opts.Force, and opts.DryRun. On a field ownership conflict it surfaces an
+// ErrFieldOwnershipConflict naming the other managers, unless opts.Force is set, in which case
+// it retries once with forced ownership.
+func (kube *KubeClient) serverSideApply(restMapping *meta.RESTMapping, u *unstructured.Unstructured, opts ApplyOptions) (*Metadata, error) {
+	metadata := &Metadata{}
+
+	fieldManager := opts.FieldManager
+	if fieldManager == "" {
+		fieldManager = defaultFieldManager
+	}
+
+	payload, err := json.Marshal(u)
+	if err != nil {
+		return metadata, errors.Wrapf(err, "failed to marshal '%s/%s' for server-side apply", u.GetNamespace(), u.GetName())
+	}
+
+	var dryRun []string
+	if opts.DryRun {
+		dryRun = []string{metav1.DryRunAll}
+	}
+
+	resourceInterface := kube.dynamicClient.Resource(restMapping.Resource)
+	namespaced := restMapping.Scope.Name() == meta.RESTScopeNameNamespace
+
+	apply := func(force bool) (*unstructured.Unstructured, error) {
+		patchOptions := metav1.PatchOptions{FieldManager: fieldManager, Force: &force, DryRun: dryRun}
+		if namespaced {
+			return resourceInterface.Namespace(u.GetNamespace()).Patch(context.TODO(), u.GetName(), types.ApplyPatchType, payload, patchOptions)
+		}
+		return resourceInterface.Patch(context.TODO(), u.GetName(), types.ApplyPatchType, payload, patchOptions)
+	}
+
+	var applied *unstructured.Unstructured
+	err = kube.withRetry(func() error {
+		var applyErr error
+		applied, applyErr = apply(false)
+		return applyErr
+	})
+	if err != nil && k8serrors.IsConflict(err) {
+		if !opts.Force {
+			return metadata, &ErrFieldOwnershipConflict{
+				Namespace:           u.GetNamespace(),
+				Name:                u.GetName(),
+				ConflictingManagers: kube.conflictingManagers(resourceInterface, namespaced, u, fieldManager),
+			}
+		}
+		err = kube.withRetry(func() error {
+			var applyErr error
+			applied, applyErr = apply(true)
+			return applyErr
+		})
+	}
+	if err != nil {
+		return metadata, err
+	}
+
+	metadata.Name = applied.GetName()
+	metadata.Namespace = applied.GetNamespace()
+	metadata.Kind = applied.GetKind()
+	metadata.Group = restMapping.Resource.Group
+	metadata.Version = restMapping.Resource.Version
+	metadata.Resource = restMapping.Resource.Resource
+	kube.invalidateDiscoveryIfCRD(metadata.Kind)
+
+	return metadata, nil
+}
+
+// conflictingManagers re-fetches the live object and reads the managers named in its
+// metadata.managedFields, leaving out self (this client's own field manager). u (the caller's
+// desired-state manifest) never carries populated managedFields of its own - the API server
+// assigns that based on apply history - so the conflicting managers can only be read off the
+// live object, not u.
+func (kube *KubeClient) conflictingManagers(resourceInterface dynamic.NamespaceableResourceInterface, namespaced bool, u *unstructured.Unstructured, self string) []string {
+	var (
+		live *unstructured.Unstructured
+		err  error
+	)
+	if namespaced {
+		live, err = resourceInterface.Namespace(u.GetNamespace()).Get(context.TODO(), u.GetName(), metav1.GetOptions{})
+	} else {
+		live, err = resourceInterface.Get(context.TODO(), u.GetName(), metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil
+	}
+
+	managedFields := live.GetManagedFields()
+	managers := make([]string, 0, len(managedFields))
+	for _, mf := range managedFields {
+		if mf.Manager == self {
+			continue
+		}
+		managers = append(managers, mf.Manager)
+	}
+	return managers
+}
@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// ResourceStatus is a normalized readiness view over the many kind-specific
+// status shapes Kubernetes resources expose.
+type ResourceStatus struct {
+	Ready              bool
+	Reason             string
+	Message            string
+	ObservedGeneration int64
+}
+
+// WaitForReady blocks until the resource identified by meta satisfies its kind-specific
+// readiness criteria, or returns an error once timeout elapses. It watches the resource rather
+// than polling, re-establishing the watch with an exponential backoff whenever it fails to
+// start, is closed by the apiserver (e.g. a 410 "resourceVersion too old"), or surfaces a
+// watch.Error event - none of which mean the resource will never become ready.
+func (kube *KubeClient) WaitForReady(meta *Metadata, timeout time.Duration) error {
+	gvk, err := kube.kindFor(schema.GroupVersionResource{Resource: meta.Kind})
+	if err != nil {
+		return err
+	}
+
+	restMapping, err := kube.restMappingFor(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if status, err := kube.ResourceStatus(meta); err == nil && status.Ready {
+		return nil
+	}
+
+	resourceClient := kube.dynamicClient.Resource(restMapping.Resource)
+	listOptions := metav1.ListOptions{FieldSelector: fmt.Sprintf("metadata.name=%s", meta.Name)}
+
+	boff := backoff.NewExponentialBackOff()
+	boff.MaxElapsedTime = timeout
+
+	timeoutErr := errors.Errorf("timed out after %s waiting for %s '%s' to become ready", timeout, meta.Kind, meta.Name)
+
+	for {
+		var watcher watch.Interface
+		err := backoff.Retry(func() error {
+			var watchErr error
+			if meta.Namespace != "" {
+				watcher, watchErr = resourceClient.Namespace(meta.Namespace).Watch(ctx, listOptions)
+			} else {
+				watcher, watchErr = resourceClient.Watch(ctx, listOptions)
+			}
+			return watchErr
+		}, backoff.WithContext(boff, ctx))
+		if err != nil {
+			return errors.Wrapf(err, "failed to watch %s '%s'", meta.Kind, meta.Name)
+		}
+
+		ready, err := waitForReadyEvent(ctx, watcher, gvk.Kind, meta.Name)
+		watcher.Stop()
+		if ready {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// The watch ended (closed by the apiserver, or a watch.Error event) without the
+		// resource becoming ready: back off and reconnect rather than giving up.
+		wait := boff.NextBackOff()
+		if wait == backoff.Stop {
+			return timeoutErr
+		}
+		select {
+		case <-ctx.Done():
+			return timeoutErr
+		case <-time.After(wait):
+		}
+	}
+}
+
+// waitForReadyEvent drains watcher's events until the resource becomes ready (returns true),
+// ctx is done (returns the timeout error), or the watch ends - either because its ResultChan
+// was closed or it surfaced a watch.Error event - in which case it returns (false, nil) so the
+// caller reconnects instead of treating either as a terminal failure.
+func waitForReadyEvent(ctx context.Context, watcher watch.Interface, kind, name string) (bool, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return false, errors.Errorf("timed out waiting for %s '%s' to become ready", kind, name)
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			if event.Type == watch.Error {
+				return false, nil
+			}
+			u, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			if status := evaluateStatus(kind, u); status.Ready {
+				return true, nil
+			}
+		}
+	}
+}
+
+// ResourceStatus returns a normalized readiness status for the resource identified by meta.
+func (kube *KubeClient) ResourceStatus(meta *Metadata) (ResourceStatus, error) {
+	u, err := kube.Get(meta.Kind, meta.Name, meta.Namespace)
+	if err != nil {
+		return ResourceStatus{}, err
+	}
+	return evaluateStatus(u.GetKind(), u), nil
+}
+
+func evaluateStatus(kind string, u *unstructured.Unstructured) ResourceStatus {
+	switch kind {
+	case "Deployment":
+		return deploymentStatus(u)
+	case "StatefulSet":
+		return statefulSetStatus(u)
+	case "DaemonSet":
+		return daemonSetStatus(u)
+	case "Job":
+		return jobStatus(u)
+	case "Service":
+		return serviceStatus(u)
+	case "PersistentVolumeClaim":
+		return pvcStatus(u)
+	default:
+		// Covers Pod and any other kind that reports a standard 'Ready' condition.
+		return conditionStatus(u)
+	}
+}
+
+func deploymentStatus(u *unstructured.Unstructured) ResourceStatus {
+	replicas, _, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	available, _, _ := unstructured.NestedInt64(u.Object, "status", "availableReplicas")
+	observedGeneration, _, _ := unstructured.NestedInt64(u.Object, "status", "observedGeneration")
+
+	status := ResourceStatus{ObservedGeneration: observedGeneration}
+	if observedGeneration < u.GetGeneration() {
+		status.Reason = "ObservedGenerationOutdated"
+		status.Message = fmt.Sprintf("observed generation %d is behind generation %d", observedGeneration, u.GetGeneration())
+		return status
+	}
+
+	if available >= replicas {
+		status.Ready = true
+		return status
+	}
+
+	status.Reason = "ReplicasUnavailable"
+	status.Message = fmt.Sprintf("%d/%d replicas available", available, replicas)
+	return status
+}
+
+func statefulSetStatus(u *unstructured.Unstructured) ResourceStatus {
+	replicas, _, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	updated, _, _ := unstructured.NestedInt64(u.Object, "status", "updatedReplicas")
+	ready, _, _ := unstructured.NestedInt64(u.Object, "status", "readyReplicas")
+
+	if updated >= replicas && ready >= replicas {
+		return ResourceStatus{Ready: true}
+	}
+	return ResourceStatus{
+		Reason:  "RolloutInProgress",
+		Message: fmt.Sprintf("updated=%d ready=%d desired=%d", updated, ready, replicas),
+	}
+}
+
+func daemonSetStatus(u *unstructured.Unstructured) ResourceStatus {
+	desired, _, _ := unstructured.NestedInt64(u.Object, "status", "desiredNumberScheduled")
+	updated, _, _ := unstructured.NestedInt64(u.Object, "status", "updatedNumberScheduled")
+	ready, _, _ := unstructured.NestedInt64(u.Object, "status", "numberReady")
+
+	if updated >= desired && ready >= desired {
+		return ResourceStatus{Ready: true}
+	}
+	return ResourceStatus{
+		Reason:  "RolloutInProgress",
+		Message: fmt.Sprintf("updated=%d ready=%d desired=%d", updated, ready, desired),
+	}
+}
+
+func jobStatus(u *unstructured.Unstructured) ResourceStatus {
+	completions, found, _ := unstructured.NestedInt64(u.Object, "spec", "completions")
+	if !found {
+		completions = 1
+	}
+
+	succeeded, _, _ := unstructured.NestedInt64(u.Object, "status", "succeeded")
+	if succeeded >= completions {
+		return ResourceStatus{Ready: true}
+	}
+	return ResourceStatus{
+		Reason:  "JobIncomplete",
+		Message: fmt.Sprintf("%d/%d completions succeeded", succeeded, completions),
+	}
+}
+
+func serviceStatus(u *unstructured.Unstructured) ResourceStatus {
+	svcType, _, _ := unstructured.NestedString(u.Object, "spec", "type")
+	if svcType != "LoadBalancer" {
+		return ResourceStatus{Ready: true}
+	}
+
+	ingress, found, _ := unstructured.NestedSlice(u.Object, "status", "loadBalancer", "ingress")
+	if found && len(ingress) > 0 {
+		return ResourceStatus{Ready: true}
+	}
+	return ResourceStatus{Reason: "LoadBalancerPending", Message: "waiting for load balancer ingress to be assigned"}
+}
+
+func pvcStatus(u *unstructured.Unstructured) ResourceStatus {
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+	if phase == "Bound" {
+		return ResourceStatus{Ready: true}
+	}
+	return ResourceStatus{Reason: "NotBound", Message: fmt.Sprintf("PersistentVolumeClaim is in phase '%s'", phase)}
+}
+
+// conditionStatus is the generic fallback readiness check: it looks for a
+// status.conditions entry of type 'Ready', which Pods and most custom resources report.
+func conditionStatus(u *unstructured.Unstructured) ResourceStatus {
+	conditions, found, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if err != nil || !found {
+		return ResourceStatus{Reason: "Unknown", Message: "no status conditions reported yet"}
+	}
+
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != "Ready" {
+			continue
+		}
+
+		reason, _ := cond["reason"].(string)
+		message, _ := cond["message"].(string)
+		return ResourceStatus{
+			Ready:   cond["status"] == "True",
+			Reason:  reason,
+			Message: message,
+		}
+	}
+
+	return ResourceStatus{Reason: "Unknown", Message: "no 'Ready' condition reported yet"}
+}